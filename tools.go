@@ -1,32 +1,82 @@
 package toolkit
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/big"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const randomStringSource = "abcdefghijklmnopqrstuvwyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 const numbers = "0123456789"
 const specialChars = "_-!@#$%^&*()"
+const lowerChars = "abcdefghijklmnopqrstuvwxyz"
+const upperChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 // Tools  is the type used to instantiate this module.
 // Any variable of this type will have access to all the method with the receiver *Tools
 type Tools struct {
-	MaxFileSize        int
-	AllowedFileTypes   []string
-	MaxJSONSize        int
-	AllowUnknownFields bool
+	MaxFileSize           int
+	MaxFilesPerUpload     int
+	AllowedFileTypes      []string
+	MaxJSONSize           int
+	AllowUnknownFields    bool
+	CompressUploads       bool
+	SigningKey            []byte
+	MaxTotalExtractedSize int64
+
+	resumableLocksMu sync.Mutex
+	resumableLocks   map[string]*sync.Mutex
+}
+
+// resumableUploadLock returns the mutex guarding reads/writes to the resumable
+// upload identified by id, creating one on first use so concurrent PATCH chunks
+// to the same upload can't race on its metadata and partial file.
+func (t *Tools) resumableUploadLock(id string) *sync.Mutex {
+	t.resumableLocksMu.Lock()
+	defer t.resumableLocksMu.Unlock()
+
+	if t.resumableLocks == nil {
+		t.resumableLocks = make(map[string]*sync.Mutex)
+	}
+
+	lock, ok := t.resumableLocks[id]
+	if !ok {
+		lock = &sync.Mutex{}
+		t.resumableLocks[id] = lock
+	}
+
+	return lock
+}
+
+// forgetResumableUploadLock discards the lock for id once the upload is finalized
+// or otherwise removed, so the lock map doesn't grow unbounded.
+func (t *Tools) forgetResumableUploadLock(id string) {
+	t.resumableLocksMu.Lock()
+	defer t.resumableLocksMu.Unlock()
+	delete(t.resumableLocks, id)
 }
 
 var DigitsOnly = func() string {
@@ -52,9 +102,9 @@ var WithAll = func() string {
 
 type RandOption func() string
 
-// TODO: extend to support minimun numbers, minimum lower/upper cases, minimum symbols...
 // RandomString returns a string of random characters of length n, using randomStringSource
-// as the source for the string
+// as the source for the string. For policy-constrained generation (minimum digits,
+// upper/lower case, symbols), see RandomStringPolicy.
 func (t *Tools) RandomString(n int, opts ...RandOption) string {
 	var source string
 
@@ -66,16 +116,128 @@ func (t *Tools) RandomString(n int, opts ...RandOption) string {
 		}
 	}
 
-	s, r := make([]rune, n), []rune(source)
+	r := []rune(source)
+	s := make([]rune, n)
 	for i := range s {
-		p, _ := rand.Prime(rand.Reader, len(r))
-		x, y := p.Uint64(), uint64(len(r))
-		s[i] = r[x%y]
+		c, err := randomRune(r)
+		if err != nil {
+			// crypto/rand reads from the OS CSPRNG, which is not expected to fail
+			panic(err)
+		}
+		s[i] = c
 	}
 
 	return string(s)
 }
 
+// randomRune picks a single rune from source using crypto/rand.Int, which is both
+// faster and less biased here than the previous rand.Prime-per-rune approach.
+func randomRune(source []rune) (rune, error) {
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(source))))
+	if err != nil {
+		return 0, err
+	}
+	return source[idx.Int64()], nil
+}
+
+// PasswordPolicy specifies minimum counts from each character class that
+// RandomStringPolicy must satisfy, plus characters to exclude (e.g. look-alikes
+// like O, 0, I, l, 1).
+type PasswordPolicy struct {
+	MinDigits  int
+	MinLower   int
+	MinUpper   int
+	MinSymbols int
+	Exclude    []rune
+}
+
+// RandomStringPolicy returns a random string of length n that satisfies policy's
+// minimum character-class counts, then shuffles the result with a Fisher-Yates
+// shuffle (using crypto/rand) so the mandated characters aren't predictably placed
+// at the front. It returns an error if n is smaller than the sum of policy's
+// minimums, or if policy requires characters from a class left empty by Exclude.
+func (t *Tools) RandomStringPolicy(n int, policy PasswordPolicy) (string, error) {
+	minSum := policy.MinDigits + policy.MinLower + policy.MinUpper + policy.MinSymbols
+	if n < minSum {
+		return "", fmt.Errorf("length %d is smaller than the sum of the policy's minimums (%d)", n, minSum)
+	}
+
+	classes := []struct {
+		min   int
+		chars []rune
+	}{
+		{policy.MinLower, excludeRunes(lowerChars, policy.Exclude)},
+		{policy.MinUpper, excludeRunes(upperChars, policy.Exclude)},
+		{policy.MinDigits, excludeRunes(numbers, policy.Exclude)},
+		{policy.MinSymbols, excludeRunes(specialChars, policy.Exclude)},
+	}
+
+	var result, allowed []rune
+	for _, class := range classes {
+		if class.min > 0 && len(class.chars) == 0 {
+			return "", errors.New("policy requires characters from a class left empty by Exclude")
+		}
+		for i := 0; i < class.min; i++ {
+			c, err := randomRune(class.chars)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, c)
+		}
+		allowed = append(allowed, class.chars...)
+	}
+
+	if len(allowed) == 0 {
+		return "", errors.New("no characters available to generate a random string from")
+	}
+
+	for len(result) < n {
+		c, err := randomRune(allowed)
+		if err != nil {
+			return "", err
+		}
+		result = append(result, c)
+	}
+
+	if err := shuffleRunes(result); err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// excludeRunes returns the runes of source with everything in exclude removed.
+func excludeRunes(source string, exclude []rune) []rune {
+	if len(exclude) == 0 {
+		return []rune(source)
+	}
+
+	excludeSet := make(map[rune]bool, len(exclude))
+	for _, r := range exclude {
+		excludeSet[r] = true
+	}
+
+	var result []rune
+	for _, r := range source {
+		if !excludeSet[r] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// shuffleRunes performs an in-place Fisher-Yates shuffle using crypto/rand.
+func shuffleRunes(r []rune) error {
+	for i := len(r) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		r[i], r[j.Int64()] = r[j.Int64()], r[i]
+	}
+	return nil
+}
+
 // UploadedFile represent the information about the uploaded file
 type UploadedFile struct {
 	NewFileName      string
@@ -115,14 +277,38 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 		return nil, err
 	}
 
+	// cap the whole request body so a single oversized stream can't be buffered
+	// into memory before we ever get a chance to inspect individual files;
+	// size it off MaxFilesPerUpload so a legitimate multi-file upload within
+	// both limits doesn't get truncated mid-stream
+	maxFiles := t.MaxFilesPerUpload
+	if maxFiles <= 0 {
+		maxFiles = 1
+	}
+	r.Body = io.NopCloser(io.LimitReader(r.Body, int64(t.MaxFileSize)*int64(maxFiles)*2))
+
 	err = r.ParseMultipartForm(int64(t.MaxFileSize))
 	if err != nil {
 		return nil, errors.New("the uploaded file is too big")
 	}
 
+	if t.MaxFilesPerUpload > 0 {
+		fileCount := 0
+		for _, fHeaders := range r.MultipartForm.File {
+			fileCount += len(fHeaders)
+		}
+		if fileCount > t.MaxFilesPerUpload {
+			return nil, fmt.Errorf("too many files in upload: %d exceeds the limit of %d", fileCount, t.MaxFilesPerUpload)
+		}
+	}
+
 	for _, fHeaders := range r.MultipartForm.File {
 		for _, hdr := range fHeaders {
 			uploadedFiles, err = func(uploadedFiles []*UploadedFile) ([]*UploadedFile, error) {
+				if hdr.Size > int64(t.MaxFileSize) {
+					return nil, fmt.Errorf("the uploaded file %s is too big", hdr.Filename)
+				}
+
 				var uploadedFile UploadedFile
 				infile, err := hdr.Open()
 				if err != nil {
@@ -165,16 +351,23 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 					uploadedFile.NewFileName = hdr.Filename
 				}
 
-				var outfile *os.File
-				defer outfile.Close()
-
-				if outfile, err = os.Create(path.Join(uploadDir, uploadedFile.NewFileName)); err != nil {
+				outfile, err := os.Create(path.Join(uploadDir, uploadedFile.NewFileName))
+				if err != nil {
 					return nil, err
 				}
-				fileSize, err := io.Copy(outfile, infile)
+				defer outfile.Close()
+
+				// enforce the size limit mid-copy, in case hdr.Size was spoofed or absent,
+				// rather than buffering the whole part before deciding to reject it
+				fileSize, err := io.Copy(outfile, io.LimitReader(infile, int64(t.MaxFileSize)+1))
 				if err != nil {
 					return nil, err
 				}
+				if fileSize > int64(t.MaxFileSize) {
+					outfile.Close()
+					_ = os.Remove(path.Join(uploadDir, uploadedFile.NewFileName))
+					return nil, fmt.Errorf("the uploaded file %s is too big", hdr.Filename)
+				}
 
 				uploadedFile.FileSize = fileSize
 				uploadedFile.OriginalFileName = hdr.Filename
@@ -229,6 +422,80 @@ func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, fi
 	http.ServeFile(w, r, fp)
 }
 
+// signedDownloadSignature computes the HMAC-SHA256 signature (hex-encoded) covering
+// file, displayName, and expires, using SigningKey.
+func (t *Tools) signedDownloadSignature(file, displayName string, expires int64) string {
+	mac := hmac.New(sha256.New, t.SigningKey)
+	fmt.Fprintf(mac, "%s|%s|%d", file, displayName, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedDownloadURL builds a URL rooted at basePath that, within ttl, can be passed
+// to ServeSignedDownload to download file (shown to the client as displayName)
+// without any other authentication. SigningKey must be set.
+func (t *Tools) SignedDownloadURL(basePath, file, displayName string, ttl time.Duration) (string, error) {
+	if len(t.SigningKey) == 0 {
+		return "", errors.New("signing key is not set")
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+
+	values := url.Values{}
+	values.Set("file", file)
+	values.Set("name", displayName)
+	values.Set("expires", strconv.FormatInt(expires, 10))
+	values.Set("sig", t.signedDownloadSignature(file, displayName, expires))
+
+	return fmt.Sprintf("%s?%s", basePath, values.Encode()), nil
+}
+
+// ServeSignedDownload validates a URL produced by SignedDownloadURL and, if the
+// signature is intact and not expired, serves the requested file out of root. The
+// served path is constrained to root to prevent directory traversal. SigningKey
+// must be set.
+func (t *Tools) ServeSignedDownload(w http.ResponseWriter, r *http.Request, root string) {
+	if len(t.SigningKey) == 0 {
+		t.ErrorJSON(w, errors.New("signing key is not set"), http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	file := query.Get("file")
+	displayName := query.Get("name")
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		t.ErrorJSON(w, errors.New("invalid or missing expiry"), http.StatusBadRequest)
+		return
+	}
+
+	if time.Now().Unix() > expires {
+		t.ErrorJSON(w, errors.New("download link has expired"), http.StatusForbidden)
+		return
+	}
+
+	expectedSig := t.signedDownloadSignature(file, displayName, expires)
+	if !hmac.Equal([]byte(expectedSig), []byte(query.Get("sig"))) {
+		t.ErrorJSON(w, errors.New("invalid download signature"), http.StatusForbidden)
+		return
+	}
+
+	cleanRoot, err := filepath.Abs(root)
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	fp := filepath.Join(cleanRoot, filepath.Clean(string(filepath.Separator)+file))
+	if fp != cleanRoot && !strings.HasPrefix(fp, cleanRoot+string(filepath.Separator)) {
+		t.ErrorJSON(w, errors.New("invalid file path"), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
+	http.ServeFile(w, r, fp)
+}
+
 // JSONResponse is the type used for sending JSON back with indication of success or failure with a message
 type JSONResponse struct {
 	Error   bool        `json:"error"`
@@ -369,3 +636,584 @@ func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.C
 	// send the response back
 	return resp, resp.StatusCode, nil
 }
+
+// PushMultipartToRemote streams fields and files to a remote endpoint as a multipart
+// form, using an io.Pipe so the request body is never buffered in memory regardless
+// of file size. It returns the response along with the number of bytes read from each
+// file (before any compression), keyed by OriginalFileName. The final parameter client is optional; if none is
+// specified, we use the standard http.Client. If CompressUploads is set, parts whose
+// detected content-type is compressible are gzipped in flight, with Content-Encoding
+// set accordingly.
+func (t *Tools) PushMultipartToRemote(uri string, fields map[string]string, files []UploadedFile, client ...*http.Client) (*http.Response, map[string]int64, error) {
+	httpClient := &http.Client{}
+	if len(client) > 0 {
+		httpClient = client[0]
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	byteCounts := make(map[string]int64)
+
+	go func() {
+		var err error
+		defer func() {
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.Close()
+		}()
+		defer writer.Close()
+
+		for key, value := range fields {
+			if err = writer.WriteField(key, value); err != nil {
+				return
+			}
+		}
+
+		for _, file := range files {
+			var written int64
+			written, err = t.writeMultipartFile(writer, file)
+			if err != nil {
+				return
+			}
+			byteCounts[file.OriginalFileName] = written
+		}
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, uri, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	return resp, byteCounts, nil
+}
+
+// writeMultipartFile opens file.NewFileName, sniffs its content-type, and writes it
+// as a single part to writer, gzipping it first when CompressUploads calls for it.
+func (t *Tools) writeMultipartFile(writer *multipart.Writer, file UploadedFile) (int64, error) {
+	f, err := os.Open(file.NewFileName)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	buff := make([]byte, 512)
+	n, err := f.Read(buff)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	contentType := http.DetectContentType(buff[:n])
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, err
+	}
+
+	compress := t.CompressUploads && isCompressibleContentType(contentType)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, file.OriginalFileName))
+	header.Set("Content-Type", contentType)
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return 0, err
+	}
+
+	if !compress {
+		return io.Copy(part, f)
+	}
+
+	gw := gzip.NewWriter(part)
+	written, err := io.Copy(gw, f)
+	if err != nil {
+		return 0, err
+	}
+	if err := gw.Close(); err != nil {
+		return 0, err
+	}
+
+	return written, nil
+}
+
+// isCompressibleContentType reports whether content of the given detected MIME type
+// benefits from gzip compression, as opposed to formats that are already compressed
+// (images, video, archives).
+func isCompressibleContentType(contentType string) bool {
+	mediaType := contentType
+	if idx := strings.Index(mediaType, ";"); idx != -1 {
+		mediaType = mediaType[:idx]
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", mediaType == "application/xml", mediaType == "application/javascript":
+		return true
+	default:
+		return false
+	}
+}
+
+// resumableUploadMeta is the sidecar state persisted alongside a partial upload,
+// so progress survives across requests (and even process restarts).
+type resumableUploadMeta struct {
+	ID               string `json:"id"`
+	OriginalFileName string `json:"original_file_name"`
+	ContentType      string `json:"content_type"`
+	Length           int64  `json:"length"`
+	Offset           int64  `json:"offset"`
+}
+
+func (t *Tools) resumableDataPath(uploadDir, id string) string {
+	return filepath.Join(uploadDir, id+".part")
+}
+
+func (t *Tools) resumableMetaPath(uploadDir, id string) string {
+	return filepath.Join(uploadDir, id+".json")
+}
+
+func (t *Tools) writeResumableMeta(uploadDir string, meta resumableUploadMeta) error {
+	out, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.resumableMetaPath(uploadDir, meta.ID), out, 0644)
+}
+
+func (t *Tools) readResumableMeta(uploadDir, id string) (resumableUploadMeta, error) {
+	var meta resumableUploadMeta
+	data, err := os.ReadFile(t.resumableMetaPath(uploadDir, id))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// parseUploadMetadata decodes a tus-style Upload-Metadata header, a comma-separated
+// list of "key base64value" pairs, into a plain map.
+func parseUploadMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(kv[1])
+		if err != nil {
+			continue
+		}
+		meta[kv[0]] = string(decoded)
+	}
+	return meta
+}
+
+// ResumableUploadHandler returns an http.Handler implementing a tus.io-style
+// resumable upload protocol: POST creates an upload, PATCH appends chunks to it
+// (identified by Upload-Offset, resuming after a dropped connection), and HEAD
+// reports how much of the upload has arrived so far. This is the subsystem to
+// reach for when UploadFiles' single-shot multipart parse isn't suitable, e.g.
+// large files over unreliable connections.
+func (t *Tools) ResumableUploadHandler(uploadDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := t.CreateDirIfNotExist(uploadDir); err != nil {
+			t.ErrorJSON(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			t.createResumableUpload(w, r, uploadDir)
+		case http.MethodHead:
+			t.resumableUploadStatus(w, r, uploadDir)
+		case http.MethodPatch:
+			t.resumableUploadPatch(w, r, uploadDir)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (t *Tools) createResumableUpload(w http.ResponseWriter, r *http.Request, uploadDir string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		t.ErrorJSON(w, errors.New("missing or invalid Upload-Length header"), http.StatusBadRequest)
+		return
+	}
+
+	if t.MaxFileSize > 0 && length > int64(t.MaxFileSize) {
+		t.ErrorJSON(w, errors.New("requested upload length exceeds the maximum allowed file size"), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	meta := resumableUploadMeta{
+		ID:               t.RandomString(25, CharactersOnly, DigitsOnly),
+		OriginalFileName: parseUploadMetadata(r.Header.Get("Upload-Metadata"))["filename"],
+		Length:           length,
+	}
+
+	if err := os.WriteFile(t.resumableDataPath(uploadDir, meta.ID), []byte{}, 0644); err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := t.writeResumableMeta(uploadDir, meta); err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", path.Join("/uploads", meta.ID))
+	w.Header().Set("Upload-Offset", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (t *Tools) resumableUploadStatus(w http.ResponseWriter, r *http.Request, uploadDir string) {
+	meta, err := t.readResumableMeta(uploadDir, path.Base(r.URL.Path))
+	if err != nil {
+		t.ErrorJSON(w, errors.New("upload not found"), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(meta.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(meta.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *Tools) resumableUploadPatch(w http.ResponseWriter, r *http.Request, uploadDir string) {
+	id := path.Base(r.URL.Path)
+
+	// serialize the read-meta -> append-chunk -> write-meta sequence per upload so
+	// two concurrent chunks to the same upload can't both append and race on offset
+	lock := t.resumableUploadLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	meta, err := t.readResumableMeta(uploadDir, id)
+	if err != nil {
+		t.forgetResumableUploadLock(id)
+		t.ErrorJSON(w, errors.New("upload not found"), http.StatusNotFound)
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		t.ErrorJSON(w, errors.New("invalid content type for upload chunk"), http.StatusBadRequest)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		t.ErrorJSON(w, errors.New("missing or invalid Upload-Offset header"), http.StatusBadRequest)
+		return
+	}
+
+	if offset != meta.Offset {
+		t.ErrorJSON(w, fmt.Errorf("upload offset mismatch: expected %d, got %d", meta.Offset, offset), http.StatusConflict)
+		return
+	}
+
+	remaining := meta.Length - meta.Offset
+	chunk, err := io.ReadAll(io.LimitReader(r.Body, remaining+1))
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	if int64(len(chunk)) > remaining {
+		t.ErrorJSON(w, errors.New("chunk exceeds the declared upload length"), http.StatusBadRequest)
+		return
+	}
+
+	if meta.Offset == 0 && len(chunk) > 0 {
+		sniffLen := len(chunk)
+		if sniffLen > 512 {
+			sniffLen = 512
+		}
+		meta.ContentType = http.DetectContentType(chunk[:sniffLen])
+
+		if len(t.AllowedFileTypes) > 0 {
+			allowed := false
+			for _, allowedType := range t.AllowedFileTypes {
+				if strings.EqualFold(meta.ContentType, allowedType) {
+					allowed = true
+				}
+			}
+			if !allowed {
+				_ = os.Remove(t.resumableDataPath(uploadDir, id))
+				_ = os.Remove(t.resumableMetaPath(uploadDir, id))
+				t.forgetResumableUploadLock(id)
+				t.ErrorJSON(w, errors.New("the uploaded file type is not permitted"), http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+	}
+
+	outfile, err := os.OpenFile(t.resumableDataPath(uploadDir, id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer outfile.Close()
+
+	if _, err := outfile.Write(chunk); err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	meta.Offset += int64(len(chunk))
+	if err := t.writeResumableMeta(uploadDir, meta); err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(meta.Offset, 10))
+
+	if meta.Offset < meta.Length {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	uploaded, err := t.finalizeResumableUpload(uploadDir, meta)
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	t.forgetResumableUploadLock(id)
+
+	_ = t.WriteJSON(w, http.StatusOK, uploaded)
+}
+
+func (t *Tools) finalizeResumableUpload(uploadDir string, meta resumableUploadMeta) (*UploadedFile, error) {
+	finalName := fmt.Sprintf("%s%s", meta.ID, filepath.Ext(meta.OriginalFileName))
+	if err := os.Rename(t.resumableDataPath(uploadDir, meta.ID), filepath.Join(uploadDir, finalName)); err != nil {
+		return nil, err
+	}
+
+	_ = os.Remove(t.resumableMetaPath(uploadDir, meta.ID))
+
+	return &UploadedFile{
+		NewFileName:      finalName,
+		OriginalFileName: meta.OriginalFileName,
+		FileSize:         meta.Length,
+	}, nil
+}
+
+// CleanupStaleUploads removes incomplete resumable uploads under dir whose metadata
+// has not been touched in the last olderThan duration, along with their partial data.
+func (t *Tools) CleanupStaleUploads(dir string, olderThan time.Duration) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		_ = os.Remove(filepath.Join(dir, entry.Name()))
+		_ = os.Remove(t.resumableDataPath(dir, id))
+		t.forgetResumableUploadLock(id)
+	}
+
+	return nil
+}
+
+type extractConfig struct {
+	overwrite bool
+}
+
+// ExtractOption configures the behaviour of ExtractArchive.
+type ExtractOption func(*extractConfig)
+
+// WithOverwrite allows ExtractArchive to overwrite files that already exist at the
+// destination. By default, ExtractArchive fails rather than clobber existing files.
+func WithOverwrite(overwrite bool) ExtractOption {
+	return func(c *extractConfig) {
+		c.overwrite = overwrite
+	}
+}
+
+// ExtractArchive safely unpacks the zip archive at archivePath into destDir, one
+// UploadedFile per extracted entry. Entries containing ".." in their path, symlinks,
+// or paths that would otherwise escape destDir are rejected outright. MaxFileSize
+// (if set) bounds each entry, and MaxTotalExtractedSize (if set) bounds the sum of
+// all entries, guarding against zip bombs.
+func (t *Tools) ExtractArchive(archivePath, destDir string, opts ...ExtractOption) ([]*UploadedFile, error) {
+	cfg := extractConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := t.CreateDirIfNotExist(destDir); err != nil {
+		return nil, err
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	destAbs, err := filepath.Abs(destDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var extractedFiles []*UploadedFile
+	var extractedPaths []string
+	var totalSize int64
+
+	// if we bail out partway through the archive, don't leave the entries we
+	// already wrote behind on disk
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		for _, p := range extractedPaths {
+			_ = os.Remove(p)
+		}
+	}()
+
+	for _, entry := range reader.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+
+		if entry.Mode()&os.ModeSymlink != 0 {
+			return nil, fmt.Errorf("refusing to extract symlink entry: %s", entry.Name)
+		}
+
+		if strings.Contains(entry.Name, "..") {
+			return nil, fmt.Errorf("refusing to extract entry with unsafe path: %s", entry.Name)
+		}
+
+		entryPath := filepath.Join(destAbs, filepath.FromSlash(entry.Name))
+		if entryPath != destAbs && !strings.HasPrefix(entryPath, destAbs+string(filepath.Separator)) {
+			return nil, fmt.Errorf("entry %s escapes the destination directory", entry.Name)
+		}
+
+		if t.MaxFileSize > 0 && int64(entry.UncompressedSize64) > int64(t.MaxFileSize) {
+			return nil, fmt.Errorf("entry %s exceeds the maximum allowed file size", entry.Name)
+		}
+
+		totalSize += int64(entry.UncompressedSize64)
+		if t.MaxTotalExtractedSize > 0 && totalSize > t.MaxTotalExtractedSize {
+			return nil, fmt.Errorf("extracted archive contents exceed the maximum allowed total size of %d bytes", t.MaxTotalExtractedSize)
+		}
+
+		preExisted := false
+		if _, err := os.Stat(entryPath); err == nil {
+			if !cfg.overwrite {
+				return nil, fmt.Errorf("destination file already exists: %s", entryPath)
+			}
+			preExisted = true
+		}
+
+		if err := t.CreateDirIfNotExist(filepath.Dir(entryPath)); err != nil {
+			return nil, err
+		}
+
+		written, err := extractZipEntry(entry, entryPath)
+		if err != nil {
+			if !preExisted {
+				_ = os.Remove(entryPath)
+			}
+			return nil, err
+		}
+
+		// only track files this call created, so a rollback never deletes a
+		// pre-existing file that WithOverwrite merely let us overwrite
+		if !preExisted {
+			extractedPaths = append(extractedPaths, entryPath)
+		}
+		extractedFiles = append(extractedFiles, &UploadedFile{
+			NewFileName:      filepath.Base(entryPath),
+			OriginalFileName: entry.Name,
+			FileSize:         written,
+		})
+	}
+
+	ok = true
+	return extractedFiles, nil
+}
+
+func extractZipEntry(entry *zip.File, destPath string) (int64, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	outfile, err := os.Create(destPath)
+	if err != nil {
+		return 0, err
+	}
+	defer outfile.Close()
+
+	return io.Copy(outfile, rc)
+}
+
+// DownloadArchiveEntry streams a single file out of the zip archive at archivePath
+// without extracting the rest of it, so callers can build artifact-browsing UIs on
+// top of uploaded archives. entry is the base64-encoded name of the zip entry to
+// serve, matching the encoding used when listing entries to the client.
+func (t *Tools) DownloadArchiveEntry(w http.ResponseWriter, r *http.Request, archivePath, entry string) {
+	decoded, err := base64.StdEncoding.DecodeString(entry)
+	if err != nil {
+		t.ErrorJSON(w, errors.New("invalid archive entry"), http.StatusBadRequest)
+		return
+	}
+	entryName := string(decoded)
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	var zipEntry *zip.File
+	for _, f := range reader.File {
+		if f.Name == entryName {
+			zipEntry = f
+			break
+		}
+	}
+
+	if zipEntry == nil {
+		t.ErrorJSON(w, errors.New("entry not found in archive"), http.StatusNotFound)
+		return
+	}
+
+	rc, err := zipEntry.Open()
+	if err != nil {
+		t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(entryName)))
+	w.Header().Set("Content-Length", strconv.FormatUint(zipEntry.UncompressedSize64, 10))
+
+	_, _ = io.Copy(w, rc)
+}
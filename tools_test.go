@@ -1,7 +1,10 @@
 package toolkit
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,10 +14,15 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestTools_RandomString(t *testing.T) {
@@ -27,6 +35,59 @@ func TestTools_RandomString(t *testing.T) {
 	}
 }
 
+func TestTools_RandomStringPolicy(t *testing.T) {
+	t.Parallel()
+	var tt Tools
+
+	policy := PasswordPolicy{
+		MinDigits:  2,
+		MinLower:   2,
+		MinUpper:   2,
+		MinSymbols: 2,
+		Exclude:    []rune{'O', '0', 'I', 'l', '1'},
+	}
+
+	s, err := tt.RandomStringPolicy(12, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s) != 12 {
+		t.Fatalf("expected len of %d, got %d", 12, len(s))
+	}
+
+	var digits, lower, upper, symbols int
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune(numbers, r):
+			digits++
+		case strings.ContainsRune(lowerChars, r):
+			lower++
+		case strings.ContainsRune(upperChars, r):
+			upper++
+		case strings.ContainsRune(specialChars, r):
+			symbols++
+		}
+		if r == 'O' || r == '0' || r == 'I' || r == 'l' || r == '1' {
+			t.Errorf("excluded rune %q present in result %q", r, s)
+		}
+	}
+
+	if digits < policy.MinDigits || lower < policy.MinLower || upper < policy.MinUpper || symbols < policy.MinSymbols {
+		t.Errorf("policy minimums not satisfied: digits=%d lower=%d upper=%d symbols=%d", digits, lower, upper, symbols)
+	}
+}
+
+func TestTools_RandomStringPolicy_TooShort(t *testing.T) {
+	t.Parallel()
+	var tt Tools
+
+	_, err := tt.RandomStringPolicy(2, PasswordPolicy{MinDigits: 2, MinUpper: 2})
+	if err == nil {
+		t.Error("expected error when n is smaller than sum of policy minimums")
+	}
+}
+
 func TestTools_UploadFiles(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -106,6 +167,55 @@ func TestTools_UploadFiles(t *testing.T) {
 	}
 }
 
+func TestTools_UploadFiles_MaxFilesPerUpload(t *testing.T) {
+	t.Parallel()
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer writer.Close()
+
+		for i := 0; i < 2; i++ {
+			part, err := writer.CreateFormFile("file", "./testdata/img.png")
+			if err != nil {
+				t.Error(err)
+			}
+
+			f, err := os.Open("./testdata/img.png")
+			if err != nil {
+				t.Error(err)
+			}
+			defer f.Close()
+
+			img, _, err := image.Decode(f)
+			if err != nil {
+				t.Error("error decoding image:", err)
+			}
+
+			if err := png.Encode(part, img); err != nil {
+				t.Error("error encoding image:", err)
+			}
+		}
+	}()
+
+	req := httptest.NewRequest("POST", "/", pr)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	var tt Tools
+	tt.MaxFilesPerUpload = 1
+
+	_, err := tt.UploadFiles(req, "./testdata/uploads/", true)
+	if err == nil {
+		t.Error("expected error when exceeding MaxFilesPerUpload, got none")
+	}
+
+	wg.Wait()
+}
+
 func TestTools_UploadOneFile(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -175,6 +285,618 @@ func TestTools_UploadOneFile(t *testing.T) {
 	}
 }
 
+func TestTools_ResumableUploadHandler(t *testing.T) {
+	t.Parallel()
+
+	uploadDir, err := os.MkdirTemp("./testdata", "resumable-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	var tt Tools
+	handler := tt.ResumableUploadHandler(uploadDir)
+
+	content := []byte("hello, resumable world!")
+
+	createReq := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	createReq.Header.Set("Upload-Length", strconv.Itoa(len(content)))
+	createReq.Header.Set("Upload-Metadata", "filename aGVsbG8udHh0")
+	createRR := httptest.NewRecorder()
+	handler.ServeHTTP(createRR, createReq)
+
+	if createRR.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, createRR.Code)
+	}
+
+	location := createRR.Header().Get("Location")
+	if location == "" {
+		t.Fatal("expected a Location header on creation")
+	}
+
+	headReq := httptest.NewRequest(http.MethodHead, location, nil)
+	headRR := httptest.NewRecorder()
+	handler.ServeHTTP(headRR, headReq)
+
+	if headRR.Header().Get("Upload-Offset") != "0" {
+		t.Errorf("expected offset 0, got %s", headRR.Header().Get("Upload-Offset"))
+	}
+
+	patchReq := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader(content))
+	patchReq.Header.Set("Content-Type", "application/offset+octet-stream")
+	patchReq.Header.Set("Upload-Offset", "0")
+	patchRR := httptest.NewRecorder()
+	handler.ServeHTTP(patchRR, patchReq)
+
+	if patchRR.Code != http.StatusOK {
+		t.Fatalf("expected status %d once upload completes, got %d", http.StatusOK, patchRR.Code)
+	}
+
+	var uploaded UploadedFile
+	if err := json.NewDecoder(patchRR.Body).Decode(&uploaded); err != nil {
+		t.Fatal(err)
+	}
+
+	if uploaded.OriginalFileName != "hello.txt" {
+		t.Errorf("expected original filename hello.txt, got %s", uploaded.OriginalFileName)
+	}
+
+	if _, err := os.Stat(path.Join(uploadDir, uploaded.NewFileName)); err != nil {
+		t.Errorf("expected finalized file to exist: %s", err)
+	}
+}
+
+func TestTools_ResumableUploadHandler_LocationIsURLSafe(t *testing.T) {
+	t.Parallel()
+
+	uploadDir, err := os.MkdirTemp("./testdata", "resumable-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	var tt Tools
+	handler := tt.ResumableUploadHandler(uploadDir)
+
+	for i := 0; i < 200; i++ {
+		createReq := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+		createReq.Header.Set("Upload-Length", "10")
+		createRR := httptest.NewRecorder()
+		handler.ServeHTTP(createRR, createReq)
+
+		location := createRR.Header().Get("Location")
+		if _, err := url.Parse(location); err != nil {
+			t.Fatalf("Location %q is not a valid URL: %s", location, err)
+		}
+	}
+}
+
+func TestTools_ResumableUploadHandler_ConcurrentChunksDontRace(t *testing.T) {
+	t.Parallel()
+
+	uploadDir, err := os.MkdirTemp("./testdata", "resumable-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	var tt Tools
+	handler := tt.ResumableUploadHandler(uploadDir)
+
+	content := []byte("0123456789")
+
+	createReq := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	createReq.Header.Set("Upload-Length", strconv.Itoa(len(content)))
+	createRR := httptest.NewRecorder()
+	handler.ServeHTTP(createRR, createReq)
+
+	location := createRR.Header().Get("Location")
+
+	var wg sync.WaitGroup
+	codes := make([]int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			patchReq := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader(content))
+			patchReq.Header.Set("Content-Type", "application/offset+octet-stream")
+			patchReq.Header.Set("Upload-Offset", "0")
+			patchRR := httptest.NewRecorder()
+			handler.ServeHTTP(patchRR, patchReq)
+			codes[i] = patchRR.Code
+		}(i)
+	}
+	wg.Wait()
+
+	completed := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			completed++
+		case http.StatusConflict, http.StatusNotFound:
+			// a loser either saw a stale offset (409) or arrived after the
+			// winner already finalized and removed the upload (404)
+		default:
+			t.Errorf("unexpected status code %d for a concurrent chunk", code)
+		}
+	}
+
+	if completed != 1 {
+		t.Errorf("expected exactly 1 chunk to complete the upload, got %d", completed)
+	}
+
+	headReq := httptest.NewRequest(http.MethodHead, location, nil)
+	headRR := httptest.NewRecorder()
+	handler.ServeHTTP(headRR, headReq)
+	if headRR.Code != http.StatusNotFound {
+		t.Errorf("expected the finalized upload to be gone, got status %d", headRR.Code)
+	}
+}
+
+func TestTools_ResumableUploadHandler_OffsetMismatch(t *testing.T) {
+	t.Parallel()
+
+	uploadDir, err := os.MkdirTemp("./testdata", "resumable-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	var tt Tools
+	handler := tt.ResumableUploadHandler(uploadDir)
+
+	createReq := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+	createReq.Header.Set("Upload-Length", "10")
+	createRR := httptest.NewRecorder()
+	handler.ServeHTTP(createRR, createReq)
+
+	location := createRR.Header().Get("Location")
+
+	patchReq := httptest.NewRequest(http.MethodPatch, location, bytes.NewReader([]byte("12345")))
+	patchReq.Header.Set("Content-Type", "application/offset+octet-stream")
+	patchReq.Header.Set("Upload-Offset", "3")
+	patchRR := httptest.NewRecorder()
+	handler.ServeHTTP(patchRR, patchReq)
+
+	if patchRR.Code != http.StatusConflict {
+		t.Errorf("expected status %d on offset mismatch, got %d", http.StatusConflict, patchRR.Code)
+	}
+}
+
+func TestTools_CleanupStaleUploads(t *testing.T) {
+	t.Parallel()
+
+	uploadDir, err := os.MkdirTemp("./testdata", "stale-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(uploadDir)
+
+	var tt Tools
+
+	if err := os.WriteFile(path.Join(uploadDir, "abc.json"), []byte(`{"id":"abc"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(uploadDir, "abc.part"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path.Join(uploadDir, "abc.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tt.CleanupStaleUploads(uploadDir, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(uploadDir, "abc.json")); !os.IsNotExist(err) {
+		t.Error("expected stale metadata file to be removed")
+	}
+	if _, err := os.Stat(path.Join(uploadDir, "abc.part")); !os.IsNotExist(err) {
+		t.Error("expected stale partial file to be removed")
+	}
+}
+
+func TestTools_PushMultipartToRemote(t *testing.T) {
+	t.Parallel()
+
+	tmpFile, err := os.CreateTemp("./testdata", "pushmultipart-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	content := []byte("some plain text content to push upstream")
+	if _, err := tmpFile.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	var receivedField string
+	var receivedBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1024 * 1024); err != nil {
+			t.Error(err)
+		}
+		receivedField = r.FormValue("foo")
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer file.Close()
+
+		receivedBody, err = io.ReadAll(file)
+		if err != nil {
+			t.Error(err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	var tt Tools
+
+	files := []UploadedFile{
+		{NewFileName: tmpFile.Name(), OriginalFileName: "notes.txt"},
+	}
+
+	resp, byteCounts, err := tt.PushMultipartToRemote(srv.URL, map[string]string{"foo": "bar"}, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if receivedField != "bar" {
+		t.Errorf("expected field foo=bar, got %s", receivedField)
+	}
+
+	if byteCounts["notes.txt"] != int64(len(content)) {
+		t.Errorf("expected byte count %d, got %d", len(content), byteCounts["notes.txt"])
+	}
+
+	if !bytes.Equal(receivedBody, content) {
+		t.Errorf("expected received body %q, got %q", content, receivedBody)
+	}
+}
+
+func TestTools_PushMultipartToRemote_CompressUploads(t *testing.T) {
+	t.Parallel()
+
+	tmpFile, err := os.CreateTemp("./testdata", "pushmultipart-gzip-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	content := []byte(strings.Repeat("some plain text content to push upstream, compressed\n", 50))
+	if _, err := tmpFile.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+
+	var receivedEncoding string
+	var receivedBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer part.Close()
+
+		receivedEncoding = part.Header.Get("Content-Encoding")
+
+		var reader io.Reader = part
+		if receivedEncoding == "gzip" {
+			gr, err := gzip.NewReader(part)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer gr.Close()
+			reader = gr
+		}
+
+		receivedBody, err = io.ReadAll(reader)
+		if err != nil {
+			t.Error(err)
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tt := Tools{CompressUploads: true}
+
+	files := []UploadedFile{
+		{NewFileName: tmpFile.Name(), OriginalFileName: "notes.txt"},
+	}
+
+	resp, byteCounts, err := tt.PushMultipartToRemote(srv.URL, nil, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if receivedEncoding != "gzip" {
+		t.Errorf("expected Content-Encoding gzip, got %q", receivedEncoding)
+	}
+
+	if byteCounts["notes.txt"] != int64(len(content)) {
+		t.Errorf("expected byte count %d, got %d", len(content), byteCounts["notes.txt"])
+	}
+
+	if !bytes.Equal(receivedBody, content) {
+		t.Errorf("expected received body to round-trip through gzip, got %d bytes", len(receivedBody))
+	}
+}
+
+func TestTools_PushMultipartToRemote_InvalidURI(t *testing.T) {
+	t.Parallel()
+
+	tmpFile, err := os.CreateTemp("./testdata", "pushmultipart-badurl-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	before := runtime.NumGoroutine()
+
+	var tt Tools
+	files := []UploadedFile{
+		{NewFileName: tmpFile.Name(), OriginalFileName: "notes.txt"},
+	}
+
+	// a control character makes http.NewRequest fail before the request is ever sent
+	_, _, err = tt.PushMultipartToRemote("http://example.com/\x7f", nil, files)
+	if err == nil {
+		t.Fatal("expected an error for an invalid URI")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Errorf("goroutine count did not return to baseline: before=%d, after=%d", before, runtime.NumGoroutine())
+}
+
+func createTestZip(t *testing.T, entries map[string]string) string {
+	t.Helper()
+
+	zipFile, err := os.CreateTemp("./testdata", "archive-*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipFile.Close()
+
+	zw := zip.NewWriter(zipFile)
+	for name, content := range entries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return zipFile.Name()
+}
+
+func TestTools_ExtractArchive(t *testing.T) {
+	t.Parallel()
+
+	archivePath := createTestZip(t, map[string]string{
+		"hello.txt":        "hello, world",
+		"nested/world.txt": "nested content",
+	})
+	defer os.Remove(archivePath)
+
+	destDir, err := os.MkdirTemp("./testdata", "extract-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destDir)
+
+	var tt Tools
+
+	files, err := tt.ExtractArchive(archivePath, destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("expected 2 extracted files, got %d", len(files))
+	}
+
+	if _, err := os.Stat(path.Join(destDir, "nested", "world.txt")); err != nil {
+		t.Errorf("expected nested file to be extracted: %s", err)
+	}
+}
+
+func TestTools_ExtractArchive_RejectsPathTraversal(t *testing.T) {
+	t.Parallel()
+
+	archivePath := createTestZip(t, map[string]string{
+		"../escape.txt": "gotcha",
+	})
+	defer os.Remove(archivePath)
+
+	destDir, err := os.MkdirTemp("./testdata", "extract-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destDir)
+
+	var tt Tools
+
+	if _, err := tt.ExtractArchive(archivePath, destDir); err == nil {
+		t.Error("expected an error for a path-traversal entry, got none")
+	}
+}
+
+func TestTools_ExtractArchive_CleansUpOnRejectedEntry(t *testing.T) {
+	t.Parallel()
+
+	zipFile, err := os.CreateTemp("./testdata", "archive-cleanup-*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(zipFile.Name())
+
+	// zip.Writer writes entries in call order, so building the archive this
+	// way (rather than from a map) guarantees first.txt lands before second.txt
+	zw := zip.NewWriter(zipFile)
+	for _, e := range []struct{ name, content string }{
+		{"first.txt", "this entry should be extracted, then cleaned up"},
+		{"second.txt", "this entry pushes the total over the limit"},
+	} {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipFile.Close()
+
+	destDir, err := os.MkdirTemp("./testdata", "extract-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destDir)
+
+	tt := Tools{MaxTotalExtractedSize: int64(len("this entry should be extracted, then cleaned up"))}
+
+	if _, err := tt.ExtractArchive(zipFile.Name(), destDir); err == nil {
+		t.Fatal("expected an error once the total extracted size is exceeded")
+	}
+
+	if _, err := os.Stat(path.Join(destDir, "first.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected first.txt to be cleaned up after the rejected extraction, stat err: %v", err)
+	}
+}
+
+func TestTools_ExtractArchive_OverwriteRollbackPreservesPreexistingFile(t *testing.T) {
+	t.Parallel()
+
+	zipFile, err := os.CreateTemp("./testdata", "archive-overwrite-*.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(zipFile.Name())
+
+	// first.txt already exists at the destination and is merely overwritten,
+	// second.txt pushes the total over the limit and triggers a rollback
+	zw := zip.NewWriter(zipFile)
+	for _, e := range []struct{ name, content string }{
+		{"first.txt", "overwritten content"},
+		{"second.txt", "this entry pushes the total over the limit"},
+	} {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipFile.Close()
+
+	destDir, err := os.MkdirTemp("./testdata", "extract-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destDir)
+
+	if err := os.WriteFile(path.Join(destDir, "first.txt"), []byte("pre-existing content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := Tools{MaxTotalExtractedSize: int64(len("overwritten content"))}
+
+	if _, err := tt.ExtractArchive(zipFile.Name(), destDir, WithOverwrite(true)); err == nil {
+		t.Fatal("expected an error once the total extracted size is exceeded")
+	}
+
+	// first.txt's own entry succeeded before second.txt tripped the size limit,
+	// so it should keep the overwritten content rather than being deleted by
+	// the rollback (which only removes files this call itself created)
+	got, err := os.ReadFile(path.Join(destDir, "first.txt"))
+	if err != nil {
+		t.Fatalf("expected first.txt to still exist after the rollback: %s", err)
+	}
+	if !bytes.Equal(got, []byte("overwritten content")) {
+		t.Errorf("expected first.txt to keep its successfully overwritten content, got %q", got)
+	}
+}
+
+func TestTools_DownloadArchiveEntry(t *testing.T) {
+	t.Parallel()
+
+	archivePath := createTestZip(t, map[string]string{
+		"report.txt": "quarterly report",
+	})
+	defer os.Remove(archivePath)
+
+	var tt Tools
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/archive/entry", nil)
+
+	tt.DownloadArchiveEntry(rr, req, archivePath, base64.StdEncoding.EncodeToString([]byte("report.txt")))
+
+	res := rr.Result()
+	defer res.Body.Close()
+
+	if res.Header.Get("Content-Disposition") != `attachment; filename="report.txt"` {
+		t.Errorf("wrong content-disposition: %s", res.Header.Get("Content-Disposition"))
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "quarterly report" {
+		t.Errorf("expected body %q, got %q", "quarterly report", string(body))
+	}
+}
+
 func TestTools_CreateDirIfNotExists(t *testing.T) {
 	t.Parallel()
 	var tt Tools
@@ -247,6 +969,88 @@ func TestTools_DownloadStaticFile(t *testing.T) {
 	}
 }
 
+func TestTools_SignedDownloadURL(t *testing.T) {
+	t.Parallel()
+
+	var tt Tools
+	tt.SigningKey = []byte("super-secret-key")
+
+	rr := httptest.NewRecorder()
+
+	signedURL, err := tt.SignedDownloadURL("/download", "pic.jpg", "puppy.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", signedURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt.ServeSignedDownload(rr, req, "./testdata")
+
+	res := rr.Result()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+	}
+
+	if res.Header.Get("Content-Disposition") != `attachment; filename="puppy.jpg"` {
+		t.Errorf("wrong content-disposition: %s", res.Header.Get("Content-Disposition"))
+	}
+}
+
+func TestTools_ServeSignedDownload_Tampered(t *testing.T) {
+	t.Parallel()
+
+	var tt Tools
+	tt.SigningKey = []byte("super-secret-key")
+
+	signedURL, err := tt.SignedDownloadURL("/download", "pic.jpg", "puppy.jpg", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tamper with the requested file after signing
+	tamperedURL := strings.Replace(signedURL, "file=pic.jpg", "file=tools.go", 1)
+	req, err := http.NewRequest("GET", tamperedURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	tt.ServeSignedDownload(rr, req, "./testdata")
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for tampered link, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestTools_ServeSignedDownload_Expired(t *testing.T) {
+	t.Parallel()
+
+	var tt Tools
+	tt.SigningKey = []byte("super-secret-key")
+
+	signedURL, err := tt.SignedDownloadURL("/download", "pic.jpg", "puppy.jpg", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", signedURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	tt.ServeSignedDownload(rr, req, "./testdata")
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for expired link, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
 // TODO: add check for the error message itself
 var jsonTests = []struct {
 	name          string